@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestGoogleVerifierDelegatesToOIDC checks that GoogleVerifier verifies ID
+// tokens locally against a JWKS, rather than round-tripping through the
+// Google tokeninfo endpoint.
+func TestGoogleVerifierDelegatesToOIDC(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kid = "test-kid"
+
+	server := newFakeOIDCProvider(t, key, kid)
+	defer server.Close()
+
+	oidc, err := NewOIDCVerifier("google", server.URL, "my-client-id")
+	if err != nil {
+		t.Fatalf("NewOIDCVerifier failed: %v", err)
+	}
+	verifier := &GoogleVerifier{oidc: oidc}
+
+	if got := verifier.GetIdentifier(); got != "google" {
+		t.Fatalf("GetIdentifier() = %q, want %q", got, "google")
+	}
+
+	now := time.Now()
+	claims := oidcClaims{
+		Iss:   server.URL,
+		Aud:   "my-client-id",
+		Sub:   "user-123",
+		Email: "user@example.com",
+		Exp:   now.Add(time.Hour).Unix(),
+		Iat:   now.Unix(),
+	}
+	idToken := signRS256IDToken(t, key, kid, claims)
+
+	params := OIDCVerifierParams{IDToken: idToken, Email: "user@example.com"}
+	payloadJSON, err := fastjson.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := fastjson.RawMessage(payloadJSON)
+
+	ok, err := verifier.VerifyRequestIdentity(&raw)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/intel-go/fastjson"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFakeOIDCProvider spins up an httptest server that serves a discovery
+// document and a JWKS containing the public half of key, so OIDCVerifier can
+// be exercised end to end without a real identity provider.
+func newFakeOIDCProvider(t *testing.T, key *rsa.PrivateKey, kid string) *httptest.Server {
+	var mux http.ServeMux
+	server := httptest.NewServer(&mux)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		doc := oidcDiscoveryDocument{
+			Issuer:  server.URL,
+			JWKSURI: server.URL + "/jwks",
+		}
+		b, err := fastjson.Marshal(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwks := jsonWebKeySet{
+			Keys: []jsonWebKey{
+				{
+					Kty: "RSA",
+					Kid: kid,
+					N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+					E:   base64.RawURLEncoding.EncodeToString(encodeRSAPublicExponent(key.PublicKey.E)),
+				},
+			},
+		}
+		b, err := fastjson.Marshal(jwks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(b)
+	})
+
+	return server
+}
+
+func encodeRSAPublicExponent(e int) []byte {
+	b := []byte{byte(e >> 16), byte(e >> 8), byte(e)}
+	i := 0
+	for i < len(b)-1 && b[i] == 0 {
+		i++
+	}
+	return b[i:]
+}
+
+// signRS256IDToken builds a signed JWT fixture for claims using key and kid.
+func signRS256IDToken(t *testing.T, key *rsa.PrivateKey, kid string, claims oidcClaims) string {
+	header := jwtHeader{Alg: "RS256", Kid: kid}
+	headerJSON, err := fastjson.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := fastjson.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headerB64 := base64.RawURLEncoding.EncodeToString(headerJSON)
+	claimsB64 := base64.RawURLEncoding.EncodeToString(claimsJSON)
+	signingInput := headerB64 + "." + claimsB64
+
+	hashed := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCVerifierRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kid = "test-kid"
+
+	server := newFakeOIDCProvider(t, key, kid)
+	defer server.Close()
+
+	verifier, err := NewOIDCVerifier("test", server.URL, "my-client-id")
+	if err != nil {
+		t.Fatalf("NewOIDCVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	claims := oidcClaims{
+		Iss:   server.URL,
+		Aud:   "my-client-id",
+		Sub:   "user-123",
+		Email: "user@example.com",
+		Exp:   now.Add(time.Hour).Unix(),
+		Iat:   now.Unix(),
+	}
+	idToken := signRS256IDToken(t, key, kid, claims)
+
+	params := OIDCVerifierParams{IDToken: idToken, Email: "user@example.com"}
+	payloadJSON, err := fastjson.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := fastjson.RawMessage(payloadJSON)
+
+	ok, err := verifier.VerifyRequestIdentity(&raw)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestOIDCVerifierRejectsWrongAudience(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kid = "test-kid"
+
+	server := newFakeOIDCProvider(t, key, kid)
+	defer server.Close()
+
+	verifier, err := NewOIDCVerifier("test", server.URL, "my-client-id")
+	if err != nil {
+		t.Fatalf("NewOIDCVerifier failed: %v", err)
+	}
+
+	now := time.Now()
+	claims := oidcClaims{
+		Iss:   server.URL,
+		Aud:   "someone-elses-client-id",
+		Email: "user@example.com",
+		Exp:   now.Add(time.Hour).Unix(),
+		Iat:   now.Unix(),
+	}
+	idToken := signRS256IDToken(t, key, kid, claims)
+
+	params := OIDCVerifierParams{IDToken: idToken, Email: "user@example.com"}
+	payloadJSON, err := fastjson.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := fastjson.RawMessage(payloadJSON)
+
+	ok, err := verifier.VerifyRequestIdentity(&raw)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
+
+func TestOIDCVerifierRejectsExpiredToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const kid = "test-kid"
+
+	server := newFakeOIDCProvider(t, key, kid)
+	defer server.Close()
+
+	verifier, err := NewOIDCVerifier("test", server.URL, "my-client-id")
+	if err != nil {
+		t.Fatalf("NewOIDCVerifier failed: %v", err)
+	}
+
+	past := time.Now().Add(-2 * time.Hour)
+	claims := oidcClaims{
+		Iss:   server.URL,
+		Aud:   "my-client-id",
+		Email: "user@example.com",
+		Exp:   past.Unix(),
+		Iat:   past.Add(-time.Minute).Unix(),
+	}
+	idToken := signRS256IDToken(t, key, kid, claims)
+
+	params := OIDCVerifierParams{IDToken: idToken, Email: "user@example.com"}
+	payloadJSON, err := fastjson.Marshal(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := fastjson.RawMessage(payloadJSON)
+
+	ok, err := verifier.VerifyRequestIdentity(&raw)
+	assert.Error(t, err)
+	assert.False(t, ok)
+}
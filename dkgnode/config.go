@@ -2,12 +2,15 @@ package dkgnode
 
 /* All useful imports */
 import (
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
 
+	"github.com/allaccessone/network/keystore"
 	"github.com/allaccessone/network/logging"
 	"github.com/caarlos0/env"
 )
@@ -15,9 +18,14 @@ import (
 type Config struct {
 	HttpServerPort string `json:"httpServerPort" env:"HTTP_SERVER_PORT"`
 	// NOTE: This is what is used for registering on the Ethereum network.
-	MainServerAddress          string `json:"mainServerAddress" env:"MAIN_SERVER_ADDRESS"`
-	EthConnection              string `json:"ethconnection" env:"ETH_CONNECTION"`
-	EthPrivateKey              string `json:"ethprivatekey" env:"ETH_PRIVATE_KEY"`
+	MainServerAddress string `json:"mainServerAddress" env:"MAIN_SERVER_ADDRESS"`
+	EthConnection     string `json:"ethconnection" env:"ETH_CONNECTION"`
+	EthPrivateKey     string `json:"ethprivatekey" env:"ETH_PRIVATE_KEY"`
+	// KeystorePath, if set, takes priority over EthPrivateKey: the node's Ethereum
+	// private key is decrypted from this v3 keystore file instead of being read
+	// out of config/env in plaintext.
+	KeystorePath               string `json:"keystorePath" env:"KEYSTORE_PATH"`
+	KeystorePassphraseEnv      string `json:"keystorePassphraseEnv" env:"KEYSTORE_PASSPHRASE_ENV"`
 	BftURI                     string `json:"bfturi" env:"BFT_URI"`
 	ABCIServer                 string `json:"abciserver" env:"ABCI_SERVER"`
 	TMP2PListenAddress         string `json:"tmp2plistenaddress" env:"TM_P2P_LISTEN_ADDRESS"`
@@ -38,12 +46,14 @@ type Config struct {
 	Endpoint          string `json:"endpoint" env:"ENDPOINT"` // Save register in smart contract
 	LogLevel          string `json:"loglevel" env:"LOG_LEVEL"`
 
-	ServeUsingTLS    bool   `json:"USE_TLS" env:"USE_TLS"`
-	UseAutoCert      bool   `json:"useAutoCert" env:"USE_AUTO_CERT"`
-	AutoCertCacheDir string `json:"autoCertCacheDir" env:"AUTO_CERT_CACHE_DIR"`
-	PublicURL        string `json:"publicURL" env:"PUBLIC_URL"`
-	ServerCert       string `json:"serverCert" env:"SERVER_CERT"`
-	ServerKey        string `json:"serverKey" env:"SERVER_KEY"`
+	ServeUsingTLS        bool   `json:"USE_TLS" env:"USE_TLS"`
+	UseAutoCert          bool   `json:"useAutoCert" env:"USE_AUTO_CERT"`
+	AutoCertCacheDir     string `json:"autoCertCacheDir" env:"AUTO_CERT_CACHE_DIR"`
+	AutoCertEmail        string `json:"autoCertEmail" env:"AUTO_CERT_EMAIL"`
+	AutoCertDirectoryURL string `json:"autoCertDirectoryURL" env:"AUTO_CERT_DIRECTORY_URL"` // e.g. Let's Encrypt staging, for testing without hitting prod rate limits
+	PublicURL            string `json:"publicURL" env:"PUBLIC_URL"`
+	ServerCert           string `json:"serverCert" env:"SERVER_CERT"`
+	ServerKey            string `json:"serverKey" env:"SERVER_KEY"`
 
 	// GoogleClientID is used for oauth verification.
 	GoogleClientID string `json:"googleClientID" env:"GOOGLE_CLIENT_ID"`
@@ -155,7 +165,7 @@ func readAndMarshallJSONConfig(configPath string, c *Config) error {
 	return nil
 }
 
-func loadConfig(configPath string) *Config {
+func loadConfig(configPath string) (*Config, error) {
 
 	// Default config is initalized here
 	conf := defaultConfigSettings()
@@ -200,9 +210,45 @@ func loadConfig(configPath string) *Config {
 		conf.P2PListenAddress = fmt.Sprintf(conf.P2PListenAddress)
 	}
 
+	if conf.KeystorePath != "" {
+		privateKey, err := loadPrivateKeyFromKeystore(&conf)
+		if err != nil {
+			// A misconfigured keystore must not fall back to conf.EthPrivateKey
+			// (the hardcoded dev default when unset): that would silently
+			// downgrade the node's root-of-trust key instead of failing loudly.
+			return nil, fmt.Errorf("failed to load private key from keystore %s: %w", conf.KeystorePath, err)
+		}
+		conf.EthPrivateKey = privateKey
+	}
+
 	logging.Infof("Final Configuration: %s", conf)
 
-	return &conf
+	return &conf, nil
+}
+
+// loadPrivateKeyFromKeystore decrypts conf.KeystorePath with the passphrase
+// held in the environment variable conf.KeystorePassphraseEnv, taking
+// priority over a plaintext EthPrivateKey when set.
+func loadPrivateKeyFromKeystore(conf *Config) (string, error) {
+	if conf.KeystorePassphraseEnv == "" {
+		return "", errors.New("keystorePassphraseEnv must be set to decrypt keystorePath")
+	}
+	passphrase := os.Getenv(conf.KeystorePassphraseEnv)
+	if passphrase == "" {
+		return "", fmt.Errorf("environment variable %s is empty", conf.KeystorePassphraseEnv)
+	}
+
+	keyJSON, err := ioutil.ReadFile(conf.KeystorePath)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(key), nil
 }
 
 func defaultConfigSettings() Config {
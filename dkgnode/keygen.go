@@ -0,0 +1,48 @@
+package dkgnode
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/allaccessone/network/keystore"
+	"github.com/allaccessone/network/logging"
+	ecrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// RunKeygenCommand implements the `dnode keygen` subcommand: it generates a
+// fresh Ethereum private key, encrypts it with a passphrase read from the
+// environment, and writes the resulting v3 keystore file to --out. Point
+// Config.KeystorePath/KeystorePassphraseEnv at the result to have loadConfig
+// use it instead of a plaintext EthPrivateKey.
+func RunKeygenCommand(args []string) error {
+	fs := flag.NewFlagSet("keygen", flag.ExitOnError)
+	out := fs.String("out", "/.torus/keystore/key.json", "path to write the encrypted keystore file to")
+	passphraseEnv := fs.String("passphraseEnv", "KEYSTORE_PASSPHRASE", "environment variable to read the keystore passphrase from")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	passphrase := os.Getenv(*passphraseEnv)
+	if passphrase == "" {
+		return fmt.Errorf("keygen: environment variable %s is empty", *passphraseEnv)
+	}
+
+	key, err := ecrypto.GenerateKey()
+	if err != nil {
+		return fmt.Errorf("keygen: failed to generate key: %w", err)
+	}
+
+	keyJSON, err := keystore.EncryptKey(ecrypto.FromECDSA(key), passphrase, keystore.DefaultScryptN, keystore.DefaultScryptP)
+	if err != nil {
+		return fmt.Errorf("keygen: failed to encrypt key: %w", err)
+	}
+
+	if err := ioutil.WriteFile(*out, keyJSON, 0600); err != nil {
+		return fmt.Errorf("keygen: failed to write keystore file: %w", err)
+	}
+
+	logging.Infof("wrote encrypted keystore for address %s to %s", ecrypto.PubkeyToAddress(key.PublicKey).Hex(), *out)
+	return nil
+}
@@ -0,0 +1,140 @@
+package dkgnode
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/allaccessone/network/logging"
+)
+
+// LetsEncryptStagingURL is Let's Encrypt's staging ACME directory. Point
+// Config.AutoCertDirectoryURL at it while testing, to avoid the production
+// directory's rate limits.
+const LetsEncryptStagingURL = "https://acme-staging-v02.api.letsencrypt.org/directory"
+
+// NewTLSServer builds the *http.Server that should serve handler, configured
+// according to conf:
+//
+//   - ServeUsingTLS == false: plain HTTP, no TLS at all.
+//   - ServeUsingTLS && UseAutoCert: certificates for PublicURL are obtained
+//     and renewed automatically via ACME (Let's Encrypt by default) and
+//     cached under AutoCertCacheDir. The returned httpChallengeServer answers
+//     the HTTP-01 challenge and must be served on port 80 alongside
+//     httpsServer.
+//   - ServeUsingTLS && !UseAutoCert: TLS using the static ServerCert/ServerKey
+//     pair; httpChallengeServer is nil.
+func NewTLSServer(conf *Config, handler http.Handler) (httpsServer *http.Server, httpChallengeServer *http.Server, err error) {
+	addr := ":" + conf.HttpServerPort
+
+	if !conf.ServeUsingTLS {
+		return &http.Server{Addr: addr, Handler: handler}, nil, nil
+	}
+
+	if conf.UseAutoCert {
+		manager, err := newAutocertManager(conf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		httpsServer = &http.Server{
+			Addr:      addr,
+			Handler:   handler,
+			TLSConfig: manager.TLSConfig(),
+		}
+		httpChallengeServer = &http.Server{
+			Addr:    ":80",
+			Handler: manager.HTTPHandler(nil),
+		}
+		return httpsServer, httpChallengeServer, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.ServerCert, conf.ServerKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("dkgnode: failed to load TLS certificate: %w", err)
+	}
+	httpsServer = &http.Server{
+		Addr:      addr,
+		Handler:   handler,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	return httpsServer, nil, nil
+}
+
+// newAutocertManager builds the autocert.Manager used when UseAutoCert is
+// set, restricting issuance to conf.PublicURL.
+func newAutocertManager(conf *Config) (*autocert.Manager, error) {
+	if conf.PublicURL == "" {
+		return nil, errors.New("dkgnode: UseAutoCert requires PublicURL to be set")
+	}
+	if conf.AutoCertCacheDir == "" {
+		return nil, errors.New("dkgnode: UseAutoCert requires AutoCertCacheDir to be set")
+	}
+
+	host, err := publicHostname(conf.PublicURL)
+	if err != nil {
+		return nil, err
+	}
+
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(host),
+		Cache:      autocert.DirCache(conf.AutoCertCacheDir),
+		Email:      conf.AutoCertEmail,
+	}
+	if conf.AutoCertDirectoryURL != "" {
+		manager.Client = &acme.Client{DirectoryURL: conf.AutoCertDirectoryURL}
+	}
+	return manager, nil
+}
+
+// publicHostname extracts the bare hostname autocert.HostWhitelist needs
+// from PublicURL, which despite its name is commonly set to a bare hostname
+// (e.g. "node.example.com") but may also be given as a full URL (e.g.
+// "https://node.example.com"): HostWhitelist matches against SNI hostnames
+// and never matches if handed a scheme.
+func publicHostname(publicURL string) (string, error) {
+	if !strings.Contains(publicURL, "://") {
+		return publicURL, nil
+	}
+	u, err := url.Parse(publicURL)
+	if err != nil {
+		return "", fmt.Errorf("dkgnode: invalid PublicURL %q: %w", publicURL, err)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("dkgnode: PublicURL %q has no hostname", publicURL)
+	}
+	return u.Hostname(), nil
+}
+
+// ListenAndServeTLS starts httpsServer (and, if non-nil, the ACME HTTP-01
+// challenge server httpChallengeServer returned by NewTLSServer), and blocks
+// until one of them returns.
+func ListenAndServeTLS(conf *Config, httpsServer, httpChallengeServer *http.Server) error {
+	errCh := make(chan error, 2)
+
+	if httpChallengeServer != nil {
+		go func() {
+			logging.Infof("serving ACME HTTP-01 challenges on %s", httpChallengeServer.Addr)
+			errCh <- httpChallengeServer.ListenAndServe()
+		}()
+	}
+
+	go func() {
+		if conf.ServeUsingTLS {
+			logging.Infof("serving HTTPS on %s", httpsServer.Addr)
+			errCh <- httpsServer.ListenAndServeTLS("", "")
+		} else {
+			logging.Infof("serving HTTP on %s", httpsServer.Addr)
+			errCh <- httpsServer.ListenAndServe()
+		}
+	}()
+
+	return <-errCh
+}
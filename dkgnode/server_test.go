@@ -0,0 +1,189 @@
+package dkgnode
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a throwaway self-signed certificate/key pair
+// for host into dir, returning their paths.
+func writeSelfSignedCert(t *testing.T, dir, host string) (certPath, keyPath string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	var certPEM bytes.Buffer
+	if err := pem.Encode(&certPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der}); err != nil {
+		t.Fatalf("failed to encode certificate: %v", err)
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	var keyPEM bytes.Buffer
+	if err := pem.Encode(&keyPEM, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}); err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+
+	certPath = filepath.Join(dir, "server.crt")
+	keyPath = filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certPath, certPEM.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM.Bytes(), 0600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+
+	return certPath, keyPath
+}
+
+func TestNewTLSServerPlainHTTP(t *testing.T) {
+	conf := &Config{HttpServerPort: "8080", ServeUsingTLS: false}
+
+	httpsServer, challengeServer, err := NewTLSServer(conf, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("NewTLSServer failed: %v", err)
+	}
+	if httpsServer.TLSConfig != nil {
+		t.Error("expected no TLSConfig for plain HTTP")
+	}
+	if challengeServer != nil {
+		t.Error("expected no challenge server for plain HTTP")
+	}
+}
+
+func TestNewTLSServerStaticCert(t *testing.T) {
+	dir := t.TempDir()
+	certPath, keyPath := writeSelfSignedCert(t, dir, "localhost")
+
+	conf := &Config{
+		HttpServerPort: "8443",
+		ServeUsingTLS:  true,
+		ServerCert:     certPath,
+		ServerKey:      keyPath,
+	}
+
+	httpsServer, challengeServer, err := NewTLSServer(conf, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("NewTLSServer failed: %v", err)
+	}
+	if challengeServer != nil {
+		t.Error("expected no ACME challenge server when UseAutoCert is false")
+	}
+	if httpsServer.TLSConfig == nil || len(httpsServer.TLSConfig.Certificates) != 1 {
+		t.Fatal("expected TLSConfig to carry the loaded certificate")
+	}
+}
+
+func TestNewTLSServerAutoCertRequiresPublicURL(t *testing.T) {
+	conf := &Config{
+		HttpServerPort:   "8443",
+		ServeUsingTLS:    true,
+		UseAutoCert:      true,
+		AutoCertCacheDir: t.TempDir(),
+	}
+
+	if _, _, err := NewTLSServer(conf, http.NotFoundHandler()); err == nil {
+		t.Fatal("expected an error when PublicURL is unset")
+	}
+}
+
+// TestNewTLSServerAutoCertHostPolicy checks the autocert.Manager is wired to
+// only issue certificates for the configured PublicURL, and that
+// AutoCertDirectoryURL lets a test point the manager at a local CA (here a
+// bare httptest.Server standing in for a real ACME directory) instead of the
+// production Let's Encrypt endpoint.
+func TestNewTLSServerAutoCertHostPolicy(t *testing.T) {
+	fakeCA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotImplemented)
+	}))
+	defer fakeCA.Close()
+
+	conf := &Config{
+		HttpServerPort:       "8443",
+		ServeUsingTLS:        true,
+		UseAutoCert:          true,
+		PublicURL:            "example.com",
+		AutoCertCacheDir:     t.TempDir(),
+		AutoCertDirectoryURL: fakeCA.URL,
+	}
+
+	manager, err := newAutocertManager(conf)
+	if err != nil {
+		t.Fatalf("newAutocertManager failed: %v", err)
+	}
+	if manager.Client == nil || manager.Client.DirectoryURL != fakeCA.URL {
+		t.Fatal("expected manager to use the configured AutoCertDirectoryURL")
+	}
+
+	if err := manager.HostPolicy(nil, "example.com"); err != nil {
+		t.Errorf("expected example.com to be allowed: %v", err)
+	}
+	if err := manager.HostPolicy(nil, "evil.com"); err == nil {
+		t.Error("expected hosts other than PublicURL to be rejected")
+	}
+
+	httpsServer, challengeServer, err := NewTLSServer(conf, http.NotFoundHandler())
+	if err != nil {
+		t.Fatalf("NewTLSServer failed: %v", err)
+	}
+	if httpsServer.TLSConfig == nil {
+		t.Fatal("expected TLSConfig to be populated for autocert")
+	}
+	if challengeServer == nil || challengeServer.Addr != ":80" {
+		t.Fatal("expected an HTTP-01 challenge server on port 80")
+	}
+}
+
+// TestNewTLSServerAutoCertHostPolicyStripsScheme checks that a PublicURL
+// given as a full URL (rather than a bare hostname) still produces a
+// HostPolicy that matches the real SNI hostname.
+func TestNewTLSServerAutoCertHostPolicyStripsScheme(t *testing.T) {
+	conf := &Config{
+		HttpServerPort:   "8443",
+		ServeUsingTLS:    true,
+		UseAutoCert:      true,
+		PublicURL:        "https://example.com",
+		AutoCertCacheDir: t.TempDir(),
+	}
+
+	manager, err := newAutocertManager(conf)
+	if err != nil {
+		t.Fatalf("newAutocertManager failed: %v", err)
+	}
+
+	if err := manager.HostPolicy(nil, "example.com"); err != nil {
+		t.Errorf("expected example.com to be allowed: %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Package keystore implements go-ethereum's v3 encrypted keystore format, so
+// the node's Ethereum private key can be stored at rest behind a passphrase
+// instead of in plaintext config or env vars.
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	keystoreVersion = 3
+	cipherName      = "aes-128-ctr"
+	kdfName         = "scrypt"
+
+	// DefaultScryptN and DefaultScryptP are the scrypt cost parameters used
+	// by EncryptKey unless the caller asks for different ones.
+	DefaultScryptN = 1 << 18 // 262144
+	DefaultScryptP = 1
+
+	scryptR       = 8
+	scryptDKLen   = 32
+	scryptSaltLen = 32
+)
+
+// encryptedKeyJSON is the on-disk v3 keystore format.
+type encryptedKeyJSON struct {
+	Version int          `json:"version"`
+	Crypto  cryptoParams `json:"crypto"`
+}
+
+type cryptoParams struct {
+	Cipher       string       `json:"cipher"`
+	CipherText   string       `json:"ciphertext"`
+	CipherParams cipherParams `json:"cipherparams"`
+	KDF          string       `json:"kdf"`
+	KDFParams    kdfParams    `json:"kdfparams"`
+	MAC          string       `json:"mac"`
+}
+
+type cipherParams struct {
+	IV string `json:"iv"`
+}
+
+type kdfParams struct {
+	N     int    `json:"n"`
+	R     int    `json:"r"`
+	P     int    `json:"p"`
+	DKLen int    `json:"dklen"`
+	Salt  string `json:"salt"`
+}
+
+// keccak256 hashes the concatenation of data using Keccak-256, matching
+// go-ethereum's keyfile MAC.
+func keccak256(data ...[]byte) []byte {
+	d := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		d.Write(b)
+	}
+	return d.Sum(nil)
+}
+
+// EncryptKey encrypts key with a scrypt-derived key from passphrase and
+// returns the serialized v3 keystore JSON.
+func EncryptKey(key []byte, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	encryptKey := derivedKey[:16]
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aesCTRXOR(encryptKey, key, iv)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := keccak256(derivedKey[16:32], cipherText)
+
+	keyJSON := encryptedKeyJSON{
+		Version: keystoreVersion,
+		Crypto: cryptoParams{
+			Cipher:       cipherName,
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: cipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          kdfName,
+			KDFParams: kdfParams{
+				N:     scryptN,
+				R:     scryptR,
+				P:     scryptP,
+				DKLen: scryptDKLen,
+				Salt:  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(mac),
+		},
+	}
+
+	return json.Marshal(keyJSON)
+}
+
+// DecryptKey reverses EncryptKey, returning an error if passphrase is wrong
+// or keyjson is corrupt (the MAC won't match).
+func DecryptKey(keyjson []byte, passphrase string) ([]byte, error) {
+	var encKey encryptedKeyJSON
+	if err := json.Unmarshal(keyjson, &encKey); err != nil {
+		return nil, err
+	}
+	if encKey.Version != keystoreVersion {
+		return nil, fmt.Errorf("keystore: unsupported version %d", encKey.Version)
+	}
+	if encKey.Crypto.Cipher != cipherName {
+		return nil, fmt.Errorf("keystore: unsupported cipher %q", encKey.Crypto.Cipher)
+	}
+	if encKey.Crypto.KDF != kdfName {
+		return nil, fmt.Errorf("keystore: unsupported kdf %q", encKey.Crypto.KDF)
+	}
+
+	salt, err := hex.DecodeString(encKey.Crypto.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid salt: %w", err)
+	}
+	cipherText, err := hex.DecodeString(encKey.Crypto.CipherText)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid ciphertext: %w", err)
+	}
+	iv, err := hex.DecodeString(encKey.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid iv: %w", err)
+	}
+	wantMAC, err := hex.DecodeString(encKey.Crypto.MAC)
+	if err != nil {
+		return nil, fmt.Errorf("keystore: invalid mac: %w", err)
+	}
+
+	p := encKey.Crypto.KDFParams
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, p.N, p.R, p.P, p.DKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	// derivedKey's length comes straight from the untrusted kdfparams.dklen
+	// field, sliced into below, and (like iv) isn't covered by the MAC check
+	// that runs before those slices - a corrupt or tampered dklen must be
+	// rejected explicitly here instead of panicking with a slice-bounds error.
+	if len(derivedKey) < 32 {
+		return nil, fmt.Errorf("keystore: invalid dklen %d, want at least 32", len(derivedKey))
+	}
+
+	gotMAC := keccak256(derivedKey[16:32], cipherText)
+	if subtle.ConstantTimeCompare(gotMAC, wantMAC) != 1 {
+		return nil, errors.New("keystore: MAC mismatch - wrong passphrase or corrupted keyfile")
+	}
+
+	// The MAC only covers derivedKey[16:32] and cipherText, not iv, so a
+	// corrupt or tampered iv must be rejected explicitly here: cipher.NewCTR
+	// panics (rather than erroring) on a length mismatch with the block size.
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("keystore: invalid iv length %d, want %d", len(iv), aes.BlockSize)
+	}
+
+	return aesCTRXOR(derivedKey[:16], cipherText, iv)
+}
+
+func aesCTRXOR(key, inText, iv []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	stream := cipher.NewCTR(block, iv)
+	outText := make([]byte, len(inText))
+	stream.XORKeyStream(outText, inText)
+	return outText, nil
+}
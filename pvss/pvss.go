@@ -0,0 +1,488 @@
+// Package pvss implements Schoenmakers' Publicly Verifiable Secret Sharing
+// scheme over the secp256k1 curve: a dealer splits a secret into n encrypted
+// shares such that any threshold of nodes can cooperate to recover it, while
+// anyone (not just the participating nodes) can verify that the encrypted
+// shares and the eventual decryptions are consistent with the committed
+// polynomial.
+package pvss
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"golang.org/x/crypto/sha3"
+)
+
+// maxHashToPointAttempts bounds the try-and-increment loop in
+// hashToPointTryAndIncrement so that adversarial input can't spin forever.
+const maxHashToPointAttempts = 256
+
+var errHashToPointExhausted = errors.New("pvss: hashToPoint did not find a valid curve point within the attempt budget")
+
+var (
+	// G is the standard secp256k1 base point.
+	G = Point{x: *fromHex("79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"), y: *fromHex("483ada7726a3c4655da4fbfc0e1108a8fd17b448a68554199c47d08ffb10d4b8")}
+	// H is a second generator, derived from G so that nobody knows log_G(H).
+	H = mustHashToPoint(G.x.Bytes())
+)
+
+func fromHex(hexStr string) *big.Int {
+	r, ok := new(big.Int).SetString(hexStr, 16)
+	if !ok {
+		panic("invalid hex in source file: " + hexStr)
+	}
+	return r
+}
+
+func mustHashToPoint(data []byte) Point {
+	p, err := hashToPoint(data, TryAndIncrement)
+	if err != nil {
+		panic(err)
+	}
+	return *p
+}
+
+// Point is an affine point on secp256k1.
+type Point struct {
+	x big.Int
+	y big.Int
+}
+
+// IndexedPoint pairs a decrypted share with the 1-based node index it came
+// from, which Recover needs to build the Lagrange coefficients.
+type IndexedPoint struct {
+	Index int
+	Value Point
+}
+
+// DLEQProof is a non-interactive zero-knowledge proof that the discrete log
+// of xG (base G) equals the discrete log of xH (base h, the second base point
+// passed to createDlEQProof), without revealing the shared secret x.
+type DLEQProof struct {
+	c  secp256k1.ModNScalar
+	r  secp256k1.ModNScalar
+	vG Point
+	vH Point
+	xG Point
+	xH Point
+}
+
+type primaryPolynomial struct {
+	coeff     []secp256k1.ModNScalar
+	threshold int
+}
+
+// Keccak256 hashes the concatenation of data using Keccak-256.
+func Keccak256(data ...[]byte) []byte {
+	d := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		d.Write(b)
+	}
+	return d.Sum(nil)
+}
+
+// HashMethod selects the technique hashToPoint uses to map arbitrary bytes
+// onto a secp256k1 point.
+type HashMethod int
+
+const (
+	// TryAndIncrement hashes the input once and increments the resulting
+	// x-coordinate until it is a valid curve x-coordinate. It leaks the number
+	// of attempts through timing, but is simple and bounded to
+	// maxHashToPointAttempts.
+	TryAndIncrement HashMethod = iota
+	// SimplifiedSWU would map onto the curve in constant time via the
+	// Simplified SWU method (RFC 9380 section 8.7), which needs a 3-isogeny
+	// from a SWU-friendly curve onto secp256k1. Not implemented yet: the
+	// isogeny map coefficients are nontrivial and haven't been vetted, so we
+	// deliberately fail closed rather than ship them unreviewed.
+	SimplifiedSWU
+)
+
+// hashToPoint maps data onto a secp256k1 point using the requested method.
+func hashToPoint(data []byte, method HashMethod) (*Point, error) {
+	switch method {
+	case TryAndIncrement:
+		return hashToPointTryAndIncrement(data)
+	case SimplifiedSWU:
+		return nil, errors.New("pvss: SimplifiedSWU hash-to-curve is not implemented yet, use TryAndIncrement")
+	default:
+		return nil, fmt.Errorf("pvss: unknown hash method %d", method)
+	}
+}
+
+// hashToPointTryAndIncrement maps data onto a secp256k1 point by taking
+// x = Keccak256(data) and incrementing x until x^3+7 is a quadratic residue
+// mod the field prime.
+func hashToPointTryAndIncrement(data []byte) (*Point, error) {
+	x := new(big.Int).SetBytes(Keccak256(data))
+	for attempt := 0; attempt < maxHashToPointAttempts; attempt++ {
+		var fx secp256k1.FieldVal
+		fx.SetByteSlice(x.Bytes())
+
+		var seven secp256k1.FieldVal
+		seven.SetInt(7)
+
+		var beta secp256k1.FieldVal
+		beta.SquareVal(&fx).Mul(&fx)
+		beta.Add(&seven).Normalize()
+
+		var y secp256k1.FieldVal
+		y.SquareRootVal(&beta)
+		y.Normalize()
+
+		var check secp256k1.FieldVal
+		check.SquareVal(&y).Normalize()
+		if check.Equals(&beta) {
+			yBytes := y.Bytes()
+			return &Point{x: *x, y: *new(big.Int).SetBytes(yBytes[:])}, nil
+		}
+		x.Add(x, big.NewInt(1))
+	}
+	return nil, errHashToPointExhausted
+}
+
+func jacobianFromPoint(p Point) secp256k1.JacobianPoint {
+	var j secp256k1.JacobianPoint
+	j.X.SetByteSlice(p.x.Bytes())
+	j.Y.SetByteSlice(p.y.Bytes())
+	j.Z.SetInt(1)
+	return j
+}
+
+func pointFromJacobian(j secp256k1.JacobianPoint) Point {
+	j.ToAffine()
+	xBytes, yBytes := j.X.Bytes(), j.Y.Bytes()
+	return Point{x: *new(big.Int).SetBytes(xBytes[:]), y: *new(big.Int).SetBytes(yBytes[:])}
+}
+
+func scalarBaseMult(k *secp256k1.ModNScalar) Point {
+	var result secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(k, &result)
+	return pointFromJacobian(result)
+}
+
+func scalarMult(p Point, k *secp256k1.ModNScalar) Point {
+	j := jacobianFromPoint(p)
+	var result secp256k1.JacobianPoint
+	secp256k1.ScalarMultNonConst(k, &j, &result)
+	return pointFromJacobian(result)
+}
+
+func pointAdd(a, b Point) Point {
+	ja, jb := jacobianFromPoint(a), jacobianFromPoint(b)
+	var result secp256k1.JacobianPoint
+	secp256k1.AddNonConst(&ja, &jb, &result)
+	return pointFromJacobian(result)
+}
+
+func scalarFromBigInt(b *big.Int) secp256k1.ModNScalar {
+	var sc secp256k1.ModNScalar
+	sc.SetByteSlice(b.Bytes())
+	return sc
+}
+
+func newScalarFromInt64(v int64) secp256k1.ModNScalar {
+	var sc secp256k1.ModNScalar
+	if v < 0 {
+		sc.SetInt(uint32(-v))
+		sc.Negate()
+	} else {
+		sc.SetInt(uint32(v))
+	}
+	return sc
+}
+
+// randomScalar returns a uniformly random non-zero scalar mod the secp256k1
+// group order, via rejection sampling.
+func randomScalar() (secp256k1.ModNScalar, error) {
+	var sc secp256k1.ModNScalar
+	for {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return sc, err
+		}
+		overflow := sc.SetByteSlice(buf)
+		if !overflow && !sc.IsZero() {
+			return sc, nil
+		}
+	}
+}
+
+// invertScalar returns in^-1 mod the secp256k1 group order.
+func invertScalar(in *secp256k1.ModNScalar) (*secp256k1.ModNScalar, error) {
+	if in.IsZero() {
+		return nil, errors.New("pvss: cannot invert zero scalar")
+	}
+	inv := new(secp256k1.ModNScalar).Set(in)
+	inv.InverseNonConst()
+	return inv, nil
+}
+
+// polyEval computes the private share v = p(i) for the polynomial with the
+// given coefficients, reduced mod the secp256k1 group order.
+func polyEval(polynomial primaryPolynomial, x int) secp256k1.ModNScalar {
+	base := newScalarFromInt64(int64(x))
+	xi := base
+	sum := polynomial.coeff[0]
+	for i := 1; i < polynomial.threshold; i++ {
+		term := xi
+		term.Mul(&polynomial.coeff[i])
+		sum.Add(&term)
+		xi.Mul(&base)
+	}
+	return sum
+}
+
+// newPrimaryPolynomial builds a random polynomial of degree threshold-1 whose
+// constant term is secret.
+func newPrimaryPolynomial(secret *big.Int, threshold int) (*primaryPolynomial, error) {
+	coeff := make([]secp256k1.ModNScalar, threshold)
+	coeff[0] = scalarFromBigInt(secret)
+	for i := 1; i < threshold; i++ {
+		c, err := randomScalar()
+		if err != nil {
+			return nil, err
+		}
+		coeff[i] = c
+	}
+	return &primaryPolynomial{coeff: coeff, threshold: threshold}, nil
+}
+
+// getCommit creates the public commitment to each coefficient of the
+// polynomial, i.e. commits[j] = coeff[j]*G.
+func getCommit(polynomial primaryPolynomial, threshold int) []Point {
+	commits := make([]Point, threshold)
+	for i := range commits {
+		commits[i] = scalarBaseMult(&polynomial.coeff[i])
+	}
+	return commits
+}
+
+// commitExponent recomputes X_i = Π_j commits[j]^(i^j), the Feldman
+// commitment to the share at index i, from the published coefficient
+// commitments.
+func commitExponent(commits []Point, index int) Point {
+	var result Point
+	first := true
+	exp := newScalarFromInt64(1)
+	idx := newScalarFromInt64(int64(index))
+	for _, commit := range commits {
+		term := scalarMult(commit, &exp)
+		if first {
+			result = term
+			first = false
+		} else {
+			result = pointAdd(result, term)
+		}
+		exp.Mul(&idx)
+	}
+	return result
+}
+
+// createDlEQProof computes a new NIZK dlog-equality proof for the scalar x
+// with respect to base points G and h. It therefore randomly selects a
+// commitment v and then computes the challenge c = H(xG,xH,vG,vH) and
+// response r = v - c*x.
+func createDlEQProof(secret secp256k1.ModNScalar, h Point) (*DLEQProof, error) {
+	xG := scalarBaseMult(&secret)
+	xH := scalarMult(h, &secret)
+
+	v, err := randomScalar()
+	if err != nil {
+		return nil, err
+	}
+	vG := scalarBaseMult(&v)
+	vH := scalarMult(h, &v)
+
+	c := dlEQChallenge(xG, xH, vG, vH)
+
+	r := c
+	r.Mul(&secret)
+	r.Negate()
+	r.Add(&v)
+
+	return &DLEQProof{c: c, r: r, vG: vG, vH: vH, xG: xG, xH: xH}, nil
+}
+
+func dlEQChallenge(xG, xH, vG, vH Point) secp256k1.ModNScalar {
+	cb := make([]byte, 0)
+	for _, p := range [4]Point{xG, xH, vG, vH} {
+		cb = append(cb, p.x.Bytes()...)
+		cb = append(cb, p.y.Bytes()...)
+	}
+	var c secp256k1.ModNScalar
+	c.SetByteSlice(Keccak256(cb))
+	return c
+}
+
+// verifyDLEQProof checks that proof is a valid dlog-equality proof for base
+// points G and h, i.e. that vG == rG + c*xG and vH == r*h + c*xH.
+func verifyDLEQProof(proof *DLEQProof, h Point) error {
+	c := dlEQChallenge(proof.xG, proof.xH, proof.vG, proof.vH)
+	if !c.Equals(&proof.c) {
+		return errors.New("pvss: dleq proof has invalid challenge")
+	}
+
+	rG := scalarBaseMult(&proof.r)
+	cXG := scalarMult(proof.xG, &proof.c)
+	checkG := pointAdd(rG, cXG)
+	if checkG.x.Cmp(&proof.vG.x) != 0 || checkG.y.Cmp(&proof.vG.y) != 0 {
+		return errors.New("pvss: dleq proof fails base G check")
+	}
+
+	rH := scalarMult(h, &proof.r)
+	cXH := scalarMult(proof.xH, &proof.c)
+	checkH := pointAdd(rH, cXH)
+	if checkH.x.Cmp(&proof.vH.x) != 0 || checkH.y.Cmp(&proof.vH.y) != 0 {
+		return errors.New("pvss: dleq proof fails base H check")
+	}
+
+	return nil
+}
+
+// EncShares splits secret into len(nodes) Shamir shares over a degree
+// threshold-1 polynomial, encrypts share i under nodes[i] (the node's
+// secp256k1 public key), and returns the encrypted shares alongside the
+// Feldman commitments to the polynomial and a DLEQ proof per share that can
+// be checked by anyone with VerifyEncShares.
+func EncShares(nodes []Point, secret *big.Int, threshold int) (encShares []Point, commits []Point, proofs []*DLEQProof, err error) {
+	n := len(nodes)
+	if threshold < 1 || threshold > n {
+		return nil, nil, nil, fmt.Errorf("pvss: threshold %d must be between 1 and the number of nodes (%d)", threshold, n)
+	}
+
+	polynomial, err := newPrimaryPolynomial(secret, threshold)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	commits = getCommit(*polynomial, threshold)
+	encShares = make([]Point, n)
+	proofs = make([]*DLEQProof, n)
+	for i, node := range nodes {
+		share := polyEval(*polynomial, i+1)
+		proof, err := createDlEQProof(share, node)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		encShares[i] = proof.xH
+		proofs[i] = proof
+	}
+
+	return encShares, commits, proofs, nil
+}
+
+// VerifyEncShares checks, for every node, that the published encrypted share
+// and DLEQ proof are consistent with the Feldman commitments to the sharing
+// polynomial. It returns a non-nil error describing the first inconsistency
+// found.
+func VerifyEncShares(nodes []Point, encShares []Point, commits []Point, proofs []*DLEQProof) error {
+	if len(nodes) != len(encShares) || len(nodes) != len(proofs) {
+		return errors.New("pvss: nodes, encShares and proofs must have equal length")
+	}
+
+	for i, node := range nodes {
+		xi := commitExponent(commits, i+1)
+		if xi.x.Cmp(&proofs[i].xG.x) != 0 || xi.y.Cmp(&proofs[i].xG.y) != 0 {
+			return fmt.Errorf("pvss: share commitment mismatch for node %d", i)
+		}
+		if err := verifyDLEQProof(proofs[i], node); err != nil {
+			return fmt.Errorf("pvss: invalid encryption proof for node %d: %w", i, err)
+		}
+		if encShares[i].x.Cmp(&proofs[i].xH.x) != 0 || encShares[i].y.Cmp(&proofs[i].xH.y) != 0 {
+			return fmt.Errorf("pvss: encrypted share mismatch for node %d", i)
+		}
+	}
+
+	return nil
+}
+
+// DecShare decrypts encShare with the node's private key priv, computing
+// S_i = x_i^{-1} * encShare, and returns a DLEQ proof (base points G and the
+// decrypted share) that lets anyone check the decryption against the node's
+// public key without learning priv.
+func DecShare(encShare Point, priv *big.Int) (decShare Point, proof *DLEQProof, err error) {
+	privScalar := scalarFromBigInt(priv)
+	modInv, err := invertScalar(&privScalar)
+	if err != nil {
+		return Point{}, nil, err
+	}
+
+	decShare = scalarMult(encShare, modInv)
+
+	proof, err = createDlEQProof(privScalar, decShare)
+	if err != nil {
+		return Point{}, nil, err
+	}
+	return decShare, proof, nil
+}
+
+// VerifyDecShare checks that decShare is the correct decryption of encShare
+// under the node's public key pub, using the DLEQ proof produced by
+// DecShare.
+func VerifyDecShare(pub Point, encShare Point, decShare Point, proof *DLEQProof) error {
+	if proof.xG.x.Cmp(&pub.x) != 0 || proof.xG.y.Cmp(&pub.y) != 0 {
+		return errors.New("pvss: proof is not bound to the node's public key")
+	}
+	if proof.xH.x.Cmp(&encShare.x) != 0 || proof.xH.y.Cmp(&encShare.y) != 0 {
+		return errors.New("pvss: proof is not bound to the encrypted share")
+	}
+	return verifyDLEQProof(proof, decShare)
+}
+
+// Recover reconstructs the secret point (the dealer's secret multiplied by
+// G) from threshold or more decrypted shares, via Lagrange interpolation in
+// the exponent.
+func Recover(threshold int, decShares []IndexedPoint) (Point, error) {
+	if len(decShares) < threshold {
+		return Point{}, fmt.Errorf("pvss: need at least %d shares to recover, got %d", threshold, len(decShares))
+	}
+	shares := decShares[:threshold]
+
+	var result Point
+	first := true
+	for i, si := range shares {
+		lambda, err := lagrangeCoefficient(shares, i)
+		if err != nil {
+			return Point{}, err
+		}
+		term := scalarMult(si.Value, &lambda)
+		if first {
+			result = term
+			first = false
+		} else {
+			result = pointAdd(result, term)
+		}
+	}
+
+	return result, nil
+}
+
+// lagrangeCoefficient computes lambda_i(0) = Π_{j!=i} (0 - x_j)/(x_i - x_j)
+// mod the secp256k1 group order, for use in exponent interpolation.
+func lagrangeCoefficient(shares []IndexedPoint, i int) (secp256k1.ModNScalar, error) {
+	var num, den secp256k1.ModNScalar
+	num.SetInt(1)
+	den.SetInt(1)
+	for j, sj := range shares {
+		if i == j {
+			continue
+		}
+		negIdx := newScalarFromInt64(int64(-sj.Index))
+		num.Mul(&negIdx)
+
+		diff := newScalarFromInt64(int64(shares[i].Index - sj.Index))
+		den.Mul(&diff)
+	}
+
+	denInv, err := invertScalar(&den)
+	if err != nil {
+		return secp256k1.ModNScalar{}, errors.New("pvss: duplicate share index during reconstruction")
+	}
+	num.Mul(denInv)
+	return num, nil
+}
@@ -1,291 +1,169 @@
 package pvss
 
 import (
-	"crypto/ecdsa"
-	"crypto/elliptic"
-	"crypto/rand"
-	"fmt"
 	"math/big"
-	"reflect"
 	"testing"
 
-	"github.com/decred/dcrd/dcrec/secp256k1"
-	"github.com/ethereum/go-ethereum/crypto/sha3"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/stretchr/testify/assert"
 )
 
-type NodeList struct {
-	Nodes []Point
-}
-
-type PrimaryPolynomial struct {
-	coeff     []big.Int
-	threshold int
-}
-
-type PrimaryShares struct {
-	Index int
-	Value big.Int
+func generateKeyPair(t *testing.T) (pub Point, priv *big.Int) {
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey := key.PubKey()
+	return Point{x: *pubKey.X(), y: *pubKey.Y()}, new(big.Int).SetBytes(key.Serialize())
 }
 
-type Point struct {
-	x big.Int
-	y big.Int
+func createRandomNodes(t *testing.T, number int) (pubs []Point, privs []*big.Int) {
+	for i := 0; i < number; i++ {
+		pub, priv := generateKeyPair(t)
+		pubs = append(pubs, pub)
+		privs = append(privs, priv)
+	}
+	return pubs, privs
 }
 
-type DLEQProof struct {
-	c  big.Int
-	r  big.Int
-	vG Point
-	vH Point
-	xG Point
-	xH Point
-}
+// isOnCurve checks y^2 == x^3+7 mod the secp256k1 field prime.
+func isOnCurve(p Point) bool {
+	var x, y secp256k1.FieldVal
+	x.SetByteSlice(p.x.Bytes())
+	y.SetByteSlice(p.y.Bytes())
 
-func fromHex(s string) *big.Int {
-	r, ok := new(big.Int).SetString(s, 16)
-	if !ok {
-		panic("invalid hex in source file: " + s)
-	}
-	return r
-}
+	var lhs secp256k1.FieldVal
+	lhs.SquareVal(&y).Normalize()
 
-var (
-	s              = secp256k1.S256()
-	fieldOrder     = fromHex("fffffffffffffffffffffffffffffffffffffffffffffffffffffffefffffc2f")
-	generatorOrder = fromHex("fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141")
-	// scalar to the power of this is like square root, eg. y^sqRoot = y^0.5 (if it exists)
-	sqRoot = fromHex("3fffffffffffffffffffffffffffffffffffffffffffffffffffffffbfffff0c")
-	G      = Point{x: *s.Gx, y: *s.Gy}
-	H      = hashToPoint(G.x.Bytes())
-)
+	var seven secp256k1.FieldVal
+	seven.SetInt(7)
+	var rhs secp256k1.FieldVal
+	rhs.SquareVal(&x).Mul(&x)
+	rhs.Add(&seven).Normalize()
 
-func Keccak256(data ...[]byte) []byte {
-	d := sha3.NewKeccak256()
-	for _, b := range data {
-		d.Write(b)
-	}
-	return d.Sum(nil)
+	return lhs.Equals(&rhs)
 }
 
-func hashToPoint(data []byte) *Point {
-	keccakHash := Keccak256(data)
-	x := new(big.Int)
-	x.SetBytes(keccakHash)
-	for {
-		beta := new(big.Int)
-		beta.Exp(x, big.NewInt(3), fieldOrder)
-		beta.Add(beta, big.NewInt(7))
-		beta.Mod(beta, fieldOrder)
-		y := new(big.Int)
-		y.Exp(beta, sqRoot, fieldOrder)
-		if new(big.Int).Exp(y, big.NewInt(2), fieldOrder).Cmp(beta) == 0 {
-			return &Point{x: *x, y: *y}
-		} else {
-			x.Add(x, big.NewInt(1))
-		}
+func TestHashToPoint(t *testing.T) {
+	res, err := hashToPoint([]byte("this is a random message"), TryAndIncrement)
+	if err != nil {
+		t.Fatal(err)
 	}
+	assert.True(t, isOnCurve(*res))
 }
 
-func TestHash(test *testing.T) {
-	res := hashToPoint([]byte("this is a random message"))
-	fmt.Println(res.x)
-	fmt.Println(res.y)
-	assert.True(test, s.IsOnCurve(&res.x, &res.y))
+func TestHashToPointSimplifiedSWUNotImplemented(t *testing.T) {
+	_, err := hashToPoint([]byte("this is a random message"), SimplifiedSWU)
+	assert.Error(t, err)
 }
 
-func assertEqual(t *testing.T, a interface{}, b interface{}) {
-	if a == b {
-		return
+func TestPolyEval(t *testing.T) {
+	coeff := make([]secp256k1.ModNScalar, 11)
+	coeff[0] = newScalarFromInt64(10)
+	for i := 1; i < 11; i++ {
+		coeff[i] = newScalarFromInt64(int64(i))
 	}
-	// debug.PrintStack()
-	t.Errorf("Received %v (type %v), expected %v (type %v)", a, reflect.TypeOf(a), b, reflect.TypeOf(b))
-}
+	polynomial := primaryPolynomial{coeff, 11}
 
-func generateKeyPair() (pubkey, privkey []byte) {
-	key, err := ecdsa.GenerateKey(secp256k1.S256(), rand.Reader)
-	if err != nil {
-		panic(err)
+	// p(1) = sum of all coefficients.
+	want := newScalarFromInt64(0)
+	for _, c := range coeff {
+		want.Add(&c)
 	}
-	pubkey = elliptic.Marshal(secp256k1.S256(), key.X, key.Y)
 
-	privkey = make([]byte, 32)
-	blob := key.D.Bytes()
-	copy(privkey[32-len(blob):], blob)
-
-	return pubkey, privkey
+	got := polyEval(polynomial, 1)
+	assert.True(t, want.Equals(&got))
 }
 
-func createRandomNodes(number int) *NodeList {
-	list := new(NodeList)
-	for i := 0; i < number; i++ {
-		list.Nodes = append(list.Nodes, *hashToPoint(randomBigInt().Bytes()))
-	}
-	return list
-}
+// TestPVSSRoundTrip exercises the full lifecycle: encrypt shares for a set of
+// nodes, verify the encryption, decrypt a threshold-sized subset, verify
+// each decryption, then reconstruct and check the original secret.
+func TestPVSSRoundTrip(t *testing.T) {
+	const n = 7
+	const threshold = 4
 
-func randomBigInt() *big.Int {
-	randomInt, _ := rand.Int(rand.Reader, fieldOrder)
-	return randomInt
-}
+	nodes, privs := createRandomNodes(t, n)
 
-// Eval computes the private share v = p(i).
-func polyEval(polynomial PrimaryPolynomial, x int) *big.Int { // get private share
-	xi := new(big.Int).SetInt64(int64(x))
-	sum := new(big.Int) //additive identity of curve = 0??? TODO: CHECK PLS
-	fmt.Println("x", x)
-	// for i := polynomial.threshold - 1; i >= 0; i-- {
-	// 	fmt.Println("i: ", i)
-	// 	sum.Mul(sum, xi)
-	// 	sum.Add(sum, &polynomial.coeff[i])
-	// }
-	// sum.Mod(sum, fieldOrder)
-	sum.Add(sum, &polynomial.coeff[0])
+	secretScalar, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretBytes := secretScalar.Bytes()
+	secret := new(big.Int).SetBytes(secretBytes[:])
 
-	for i := 1; i < polynomial.threshold; i++ {
-		tmp := new(big.Int).Mul(xi, &polynomial.coeff[i])
-		sum.Add(sum, tmp)
-		sum.Mod(sum, fieldOrder)
-		xi.Mul(xi, xi)
-		xi.Mod(xi, fieldOrder)
-		fmt.Println(sum.Text(10))
+	encShares, commits, encProofs, err := EncShares(nodes, secret, threshold)
+	if err != nil {
+		t.Fatalf("EncShares failed: %v", err)
 	}
-	return sum
-}
 
-func TestPolyEval(test *testing.T) {
-	coeff := make([]big.Int, 11)
-	coeff[0] = *big.NewInt(10) //assign secret as coeff of x^0
-	for i := 1; i < 11; i++ {  //randomly choose coeffs
-		coeff[i] = *big.NewInt(int64(i))
+	if err := VerifyEncShares(nodes, encShares, commits, encProofs); err != nil {
+		t.Fatalf("VerifyEncShares failed: %v", err)
 	}
-	fmt.Println(coeff)
-	polynomial := PrimaryPolynomial{coeff, 11}
-	fmt.Println(polyEval(polynomial, 1))
 
-}
+	// Decrypt an arbitrary threshold-sized subset of the shares.
+	subset := []int{0, 2, 3, 5}
+	assert.Len(t, subset, threshold)
 
-func getShares(polynomial PrimaryPolynomial, n int) []big.Int {
-	shares := make([]big.Int, n)
-	for i := range shares {
-		shares[i] = *polyEval(polynomial, i+1)
+	decShares := make([]IndexedPoint, len(subset))
+	for k, i := range subset {
+		decShare, decProof, err := DecShare(encShares[i], privs[i])
+		if err != nil {
+			t.Fatalf("DecShare failed for node %d: %v", i, err)
+		}
+		if err := VerifyDecShare(nodes[i], encShares[i], decShare, decProof); err != nil {
+			t.Fatalf("VerifyDecShare failed for node %d: %v", i, err)
+		}
+		decShares[k] = IndexedPoint{Index: i + 1, Value: decShare}
 	}
-	return shares
-}
 
-// Commit creates a public commitment polynomial for the given base point b or
-// the standard base if b == nil.
-func getCommit(polynomial PrimaryPolynomial, threshold int) []Point {
-	commits := make([]Point, threshold)
-	for i := range commits {
-		x, y := s.ScalarBaseMult(polynomial.coeff[i].Bytes())
-		commits[i] = Point{x: *x, y: *y}
+	secretPoint, err := Recover(threshold, decShares)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
 	}
-	return commits
-}
-
-// NewDLEQProof computes a new NIZK dlog-equality proof for the scalar x with
-// respect to base points G and H. It therefore randomly selects a commitment v
-// and then computes the challenge c = H(xG,xH,vG,vH) and response r = v - cx.
-// Besides the proof, this function also returns the encrypted base points xG
-// and xH.
-func createDlEQProof(secret big.Int, nodePubKey Point) *DLEQProof {
-	//Encrypt bbase points with secret
-	x, y := s.ScalarBaseMult(secret.Bytes())
-	xG := Point{x: *x, y: *y}
-	x2, y2 := s.ScalarMult(&nodePubKey.x, &nodePubKey.y, secret.Bytes())
-	xH := Point{x: *x2, y: *y2}
 
-	// Commitment
-	v := randomBigInt()
-	x3, y3 := s.ScalarBaseMult(v.Bytes())
-	x4, y4 := s.ScalarMult(&nodePubKey.x, &nodePubKey.y, v.Bytes())
-	vG := Point{x: *x3, y: *y3}
-	vH := Point{x: *x4, y: *y4}
+	want := scalarBaseMult(&secretScalar)
+	assert.Equal(t, &want.x, &secretPoint.x)
+	assert.Equal(t, &want.y, &secretPoint.y)
+}
 
-	//Concat hashing bytes
-	cb := make([]byte, 0)
-	for _, element := range [4]Point{xG, xH, vG, vH} {
-		cb = append(cb[:], element.x.Bytes()...)
-		cb = append(cb[:], element.y.Bytes()...)
+func TestVerifyEncSharesRejectsTamperedShare(t *testing.T) {
+	nodes, _ := createRandomNodes(t, 3)
+	secretScalar, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
 	}
+	secretBytes := secretScalar.Bytes()
+	secret := new(big.Int).SetBytes(secretBytes[:])
 
-	//hash
-	hashed := Keccak256(cb)
-	c := new(big.Int).SetBytes(hashed)
+	encShares, commits, proofs, err := EncShares(nodes, secret, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	//response
-	r := new(big.Int)
-	r.Mul(c, &secret)
-	r.Mod(r, fieldOrder)
-	r.Sub(v, r) //do we need to mod here?
+	one := newScalarFromInt64(1)
+	encShares[0] = scalarBaseMult(&one)
 
-	return &DLEQProof{*c, *r, vG, vH, xG, xH}
+	assert.Error(t, VerifyEncShares(nodes, encShares, commits, proofs))
 }
 
-func batchCreateDLEQProof(nodes []Point, shares []PrimaryShares) []*DLEQProof {
-	if len(nodes) != len(shares) {
-		return nil
-	}
-	proofs := make([]*DLEQProof, len(nodes))
-	for i := range nodes {
-		proofs[i] = createDlEQProof(shares[i].Value, nodes[i])
+func TestVerifyDecShareRejectsWrongPublicKey(t *testing.T) {
+	nodes, privs := createRandomNodes(t, 3)
+	secretScalar, err := randomScalar()
+	if err != nil {
+		t.Fatal(err)
 	}
-	return proofs
-}
+	secretBytes := secretScalar.Bytes()
+	secret := new(big.Int).SetBytes(secretBytes[:])
 
-func encShares(nodes []Point, secret big.Int, threshold int) {
-	n := len(nodes)
-	encryptedShares := make([]big.Int, n)
-	// Create secret sharing polynomial
-	coeff := make([]big.Int, threshold)
-	coeff[0] = secret                //assign secret as coeff of x^0
-	for i := 1; i < threshold; i++ { //randomly choose coeffs
-		coeff[i] = *randomBigInt()
+	encShares, _, _, err := EncShares(nodes, secret, 2)
+	if err != nil {
+		t.Fatal(err)
 	}
-	polynomial := PrimaryPolynomial{coeff, threshold}
-
-	// determine shares for polynomial with respect to basis H
-	shares := getShares(polynomial, n)
-
-	//committing Yi and proof
-	commits := getCommit(polynomial, threshold)
-
-	// Create NIZK discrete-logarithm equality proofs
-	fmt.Println(encryptedShares, shares, commits)
-
-}
-
-// DecryptShare first verifies the encrypted share against the encryption
-// consistency proof and, if valid, decrypts it and creates a decryption
-// consistency proof.
-func DecShare(encShareX big.Int, encShareY big.Int, consistencyProof big.Int, key ecdsa.PrivateKey) big.Int {
-	// if err := VerifyEncShare(suite, H, X, sH, encShare); err != nil {
-	// 	return nil, err
-	// }
-	// G := suite.Point().Base()
-	// V := suite.Point().Mul(suite.Scalar().Inv(x), encShare.S.V) // decryption: x^{-1} * (xS)
-	modInv := new(big.Int)
-	modInv.ModInverse(generatorOrder, key.D)
-	// V := s.ScalarMult(encSharexX, encShareY, modInv.Bytes())
-	// ps := &share.PubShare{I: encShare.S.I, V: V}
-	// P, _, _, err := dleq.NewDLEQProof(suite, G, V, x)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// return &PubVerShare{*ps, *P}, nil
-	i := new(big.Int)
-	return *i
-}
 
-func TestRandom(test *testing.T) {
+	decShare, decProof, err := DecShare(encShares[0], privs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	assert.Error(t, VerifyDecShare(nodes[1], encShares[0], decShare, decProof))
 }
-
-// func TestPVSS(test *testing.T) {
-// 	nodeList := createRandomNodes(10)
-// 	secret := randomBigInt()
-// 	// fmt.Println(len(nodeList))
-// 	fmt.Println("ENCRYPTING SHARES ----------------------------------")
-// 	encShares(nodeList.Nodes, *secret, 3)
-// }